@@ -0,0 +1,143 @@
+# MIT License
+# 
+# Copyright (c) 2024 Oren Collaco
+# 
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+# 
+# The above copyright notice and this permission notice shall be included in all
+# copies or substantial portions of the Software.
+# 
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+import os
+import tempfile
+import unittest
+from unittest import mock
+
+import sys
+sys.path.append('..') 
+import bootstrap
+from bootstrap import load_devlmignore, is_devlm_ignored, generate_project_structure
+
+class TestDevlmIgnore(unittest.TestCase):
+    def setUp(self):
+        self.root = tempfile.TemporaryDirectory()
+        self.root_dir = os.path.realpath(self.root.name)
+        # DevLM runs from the project root
+        self.previous_cwd = os.getcwd()
+        os.chdir(self.root_dir)
+
+    def tearDown(self):
+        os.chdir(self.previous_cwd)
+        self.root.cleanup()
+
+    def write_ignore(self, content):
+        with open(os.path.join(self.root_dir, ".devlmignore"), 'w') as f:
+            f.write(content)
+        return load_devlmignore(self.root_dir)
+
+    def ignored(self, path, patterns, is_dir=False):
+        return is_devlm_ignored(path, is_dir, patterns, self.root_dir)
+
+    def test_no_ignore_file(self):
+        self.assertEqual(load_devlmignore(self.root_dir), [])
+        self.assertFalse(is_devlm_ignored('anything.py', False, None, self.root_dir))
+
+    def test_comments_and_blank_lines(self):
+        patterns = self.write_ignore("# comment\n\n*.log\n")
+        self.assertEqual(len(patterns), 1)
+
+    def test_basename_pattern_matches_at_any_depth(self):
+        patterns = self.write_ignore("*.log\n")
+        self.assertTrue(self.ignored('app.log', patterns))
+        self.assertTrue(self.ignored('a/b/app.log', patterns))
+        self.assertFalse(self.ignored('a/b/app.py', patterns))
+
+    def test_double_star_pattern_matches_at_any_depth(self):
+        patterns = self.write_ignore("**/foo/bar\n")
+        self.assertTrue(self.ignored('foo/bar', patterns))
+        self.assertTrue(self.ignored('a/foo/bar', patterns))
+        self.assertTrue(self.ignored('a/b/foo/bar/baz.py', patterns))
+        self.assertFalse(self.ignored('foo/baz', patterns))
+        self.assertFalse(self.ignored('a/foo', patterns, is_dir=True))
+
+    def test_leading_slash_anchors_to_root(self):
+        patterns = self.write_ignore("/dist\n")
+        self.assertTrue(self.ignored('dist', patterns, is_dir=True))
+        self.assertTrue(self.ignored('dist/bundle.js', patterns))
+        self.assertFalse(self.ignored('web/dist/bundle.js', patterns))
+
+    def test_middle_slash_anchors_to_root(self):
+        patterns = self.write_ignore("docs/internal\n")
+        self.assertTrue(self.ignored('docs/internal/adr.md', patterns))
+        self.assertFalse(self.ignored('web/docs/internal/adr.md', patterns))
+
+    def test_trailing_slash_matches_directories_only(self):
+        patterns = self.write_ignore("secrets/\n")
+        self.assertTrue(self.ignored('secrets', patterns, is_dir=True))
+        self.assertTrue(self.ignored('secrets/key.pem', patterns))
+        self.assertTrue(self.ignored('config/secrets/key.pem', patterns))
+        self.assertFalse(self.ignored('secrets', patterns, is_dir=False))
+
+    def test_negation_reincludes_file(self):
+        patterns = self.write_ignore("*.log\n!keep.log\n")
+        self.assertTrue(self.ignored('app.log', patterns))
+        self.assertFalse(self.ignored('keep.log', patterns))
+        self.assertFalse(self.ignored('a/keep.log', patterns))
+
+    def test_negation_cannot_reinclude_file_in_ignored_directory(self):
+        patterns = self.write_ignore("build/\n!build/keep.txt\n")
+        self.assertTrue(self.ignored('build/keep.txt', patterns))
+
+    def test_leading_slash_in_path_is_project_relative(self):
+        patterns = self.write_ignore("/dist\n")
+        self.assertTrue(self.ignored('/dist/bundle.js', patterns))
+
+    def test_absolute_path_inside_project(self):
+        patterns = self.write_ignore("/dist\n")
+        self.assertTrue(self.ignored(os.path.join(self.root_dir, 'dist', 'b.js'), patterns))
+        self.assertFalse(self.ignored(os.path.join(self.root_dir, 'web', 'dist', 'b.js'), patterns))
+
+    def test_path_outside_project_is_not_ignored(self):
+        patterns = self.write_ignore("*.log\n")
+        with tempfile.TemporaryDirectory() as other_dir:
+            outside_file = os.path.join(other_dir, 'app.log')
+            open(outside_file, 'w').close()
+            self.assertFalse(self.ignored(outside_file, patterns))
+
+    def test_paths_after_cd_use_project_root_ignore_file(self):
+        os.makedirs(os.path.join(self.root_dir, 'dist'))
+        os.makedirs(os.path.join(self.root_dir, 'api', 'secrets'))
+        self.write_ignore("/dist\nsecrets/\n")
+        with mock.patch.object(bootstrap, 'PROJECT_ROOT', self.root_dir):
+            os.chdir(os.path.join(self.root_dir, 'api'))
+            self.assertTrue(is_devlm_ignored('secrets/key.pem'))
+            self.assertTrue(is_devlm_ignored('../dist/b.js'))
+            self.assertFalse(is_devlm_ignored('main.go'))
+            os.chdir(os.path.join(self.root_dir, 'dist'))
+            self.assertTrue(is_devlm_ignored('b.js'))
+
+    def test_project_structure_skips_ignored_entries(self):
+        os.makedirs(os.path.join(self.root_dir, 'src'))
+        os.makedirs(os.path.join(self.root_dir, 'secrets'))
+        for path in ['src/main.py', 'src/debug.log', 'secrets/key.pem', 'keep.log']:
+            open(os.path.join(self.root_dir, path), 'w').close()
+        self.write_ignore("*.log\n!keep.log\nsecrets/\n")
+        structure = generate_project_structure(self.root_dir)
+        self.assertEqual(structure[""], ['keep.log'])
+        self.assertEqual(structure["src"][""], ['main.py'])
+        self.assertNotIn("secrets", structure)
+
+if __name__ == '__main__':
+    unittest.main()
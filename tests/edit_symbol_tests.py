@@ -0,0 +1,171 @@
+# MIT License
+# 
+# Copyright (c) 2024 Oren Collaco
+# 
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+# 
+# The above copyright notice and this permission notice shall be included in all
+# copies or substantial portions of the Software.
+# 
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+
+import os
+import shutil
+import tempfile
+import unittest
+from unittest import mock
+
+import sys
+sys.path.append('..') 
+from bootstrap import replace_symbol, format_source, format_for_write, compare_and_write, find_symbol_lines, blank_literals
+
+PYTHON_SOURCE = """class Server:
+    def start(self):
+        return 1
+
+    def stop(self):
+        pass
+"""
+
+GO_SOURCE = """package server
+
+type Server struct {
+	port int
+}
+
+func (s *Server) Start() error {
+	return nil
+}
+"""
+
+GO_LITERAL_SOURCE = """package x
+
+func A() string {
+	return "{"
+}
+
+// B closes } in a comment
+func B() string {
+	return "}" + string('}') + `
+}`
+}
+
+func C() {
+	/* { */
+}
+"""
+
+class TestBlankLiterals(unittest.TestCase):
+    def test_blanks_strings_runes_and_comments(self):
+        self.assertEqual(blank_literals('a("{", \'}\', `}`) // }\nb /* { */ c'), 'a(" ", \' \', ` `)     \nb         c')
+
+    def test_escaped_quotes(self):
+        self.assertEqual(blank_literals('x := "\\"{" + y'), 'x := "   " + y')
+
+    def test_raw_strings_keep_newlines(self):
+        self.assertEqual(blank_literals('`{\n}` {'), '` \n ` {')
+
+class TestReplaceSymbol(unittest.TestCase):
+    def test_replaces_python_method(self):
+        new_content = replace_symbol(PYTHON_SOURCE, "app.py", "Server.start", "    def start(self):\n        return 2")
+        self.assertEqual(new_content, PYTHON_SOURCE.replace("return 1", "return 2"))
+
+    def test_inserts_missing_python_method_into_class(self):
+        new_content = replace_symbol(PYTHON_SOURCE, "app.py", "Server.restart", "    def restart(self):\n        self.stop()")
+        self.assertTrue(new_content.endswith("        pass\n\n    def restart(self):\n        self.stop()\n"))
+
+    def test_inserting_into_missing_class_fails(self):
+        with self.assertRaises(ValueError):
+            replace_symbol(PYTHON_SOURCE, "app.py", "Client.start", "    def start(self):\n        pass")
+
+    def test_replaces_go_receiver_method(self):
+        new_content = replace_symbol(GO_SOURCE, "server.go", "Server.Start", "func (s *Server) Start() error {\n\treturn s.listen()\n}")
+        self.assertEqual(new_content, GO_SOURCE.replace("return nil", "return s.listen()"))
+
+    def test_appends_missing_go_method(self):
+        new_content = replace_symbol(GO_SOURCE, "server.go", "Server.Stop", "func (s *Server) Stop() {}")
+        self.assertEqual(new_content, GO_SOURCE + "\nfunc (s *Server) Stop() {}\n")
+
+    def test_braces_in_go_literals_do_not_change_spans(self):
+        self.assertEqual(find_symbol_lines(GO_LITERAL_SOURCE, "x.go", "A"), (3, 5))
+        self.assertEqual(find_symbol_lines(GO_LITERAL_SOURCE, "x.go", "B"), (8, 11))
+        self.assertEqual(find_symbol_lines(GO_LITERAL_SOURCE, "x.go", "C"), (13, 15))
+
+    def test_replacing_go_function_keeps_the_next_one(self):
+        new_content = replace_symbol(GO_LITERAL_SOURCE, "x.go", "A", 'func A() string {\n\treturn "["\n}')
+        self.assertEqual(new_content, GO_LITERAL_SOURCE.replace('return "{"', 'return "["'))
+
+class TestFormatSource(unittest.TestCase):
+    def test_valid_python(self):
+        self.assertEqual(format_source(PYTHON_SOURCE, "app.py"), (PYTHON_SOURCE, None))
+
+    def test_invalid_python_is_rejected(self):
+        content, error = format_source("def broken(:\n    pass\n", "app.py")
+        self.assertIn("Syntax error at line 1", error)
+
+    def test_other_files_are_unchanged(self):
+        self.assertEqual(format_source("key: value", "config.yaml"), ("key: value", None))
+
+    @unittest.skipIf(shutil.which('gofmt') is None, "gofmt is not installed")
+    def test_go_is_formatted(self):
+        content, error = format_source("package server\nfunc Start()  error {\nreturn nil\n}\n", "server.go")
+        self.assertIsNone(error)
+        self.assertEqual(content, "package server\n\nfunc Start() error {\n\treturn nil\n}\n")
+
+    @unittest.skipIf(shutil.which('gofmt') is None, "gofmt is not installed")
+    def test_invalid_go_is_rejected(self):
+        content, error = format_source("package server\nfunc Start( {\n", "server.go")
+        self.assertIn("gofmt failed: server.go:", error)
+
+    def test_go_is_refused_without_gofmt(self):
+        with mock.patch('bootstrap.shutil.which', return_value=None):
+            content, error = format_source(GO_SOURCE, "server.go")
+        self.assertIn("gofmt is not installed", error)
+
+class TestFormatOnWrite(unittest.TestCase):
+    def setUp(self):
+        self.temp_dir = tempfile.TemporaryDirectory()
+
+    def tearDown(self):
+        self.temp_dir.cleanup()
+
+    def test_formatter_failure_does_not_block_write(self):
+        content, warning = format_for_write("def broken(:\n", "app.py")
+        self.assertEqual(content, "def broken(:\n")
+        self.assertIn("Syntax error", warning)
+
+    def test_go_is_written_unformatted_without_gofmt(self):
+        with mock.patch('bootstrap.shutil.which', return_value=None):
+            self.assertEqual(format_for_write(GO_SOURCE, "server.go"), (GO_SOURCE, None))
+
+    @unittest.skipIf(shutil.which('gofmt') is None, "gofmt is not installed")
+    def test_compare_and_write_stores_formatted_go(self):
+        file_path = os.path.join(self.temp_dir.name, "server.go")
+        with open(file_path, 'w') as f:
+            f.write(GO_SOURCE)
+        changed, diff = compare_and_write(file_path, GO_SOURCE.replace("\treturn nil", "return  nil // done"))
+        with open(file_path) as f:
+            self.assertEqual(f.read(), GO_SOURCE.replace("\treturn nil", "\treturn nil // done"))
+        self.assertTrue(changed)
+
+    @unittest.skipIf(shutil.which('gofmt') is None, "gofmt is not installed")
+    def test_formatting_only_change_is_not_a_change(self):
+        file_path = os.path.join(self.temp_dir.name, "server.go")
+        with open(file_path, 'w') as f:
+            f.write(GO_SOURCE)
+        self.assertEqual(compare_and_write(file_path, GO_SOURCE.replace("\t", "    ")), (False, None))
+
+if __name__ == '__main__':
+    unittest.main()
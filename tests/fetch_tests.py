@@ -0,0 +1,139 @@
+# MIT License
+# 
+# Copyright (c) 2024 Oren Collaco
+# 
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+# 
+# The above copyright notice and this permission notice shall be included in all
+# copies or substantial portions of the Software.
+# 
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+
+import os
+import tempfile
+import unittest
+from unittest import mock
+
+import sys
+sys.path.append('..') 
+import bootstrap
+from bootstrap import html_to_markdown, fetch_url
+
+PAGE = """<html><head><title>Widget API</title><style>body { color: red; }</style></head>
+<body>
+<nav><a href="/">Home</a> <a href="/docs">Docs</a></nav>
+<header>Site banner</header>
+<main>
+<h1>Widgets</h1>
+<p>Create a widget with <code>widget.New(name)</code>. See <a href="https://example.com/ref">the reference</a>.</p>
+<ul><li>Fast</li><li>Small</li></ul>
+<pre>w := widget.New("a")
+w.Run()</pre>
+<script>trackPageView();</script>
+</main>
+<footer>Copyright</footer>
+</body></html>"""
+
+class TestHTMLToMarkdown(unittest.TestCase):
+    def test_keeps_main_content_as_markdown(self):
+        markdown = html_to_markdown(PAGE)
+        self.assertTrue(markdown.startswith("# Widget API"))
+        self.assertIn("# Widgets", markdown)
+        self.assertIn("`widget.New(name)`", markdown)
+        self.assertIn("[the reference](https://example.com/ref)", markdown)
+        self.assertIn("- Fast", markdown)
+        self.assertIn('```\nw := widget.New("a")\nw.Run()\n```', markdown)
+
+    def test_drops_boilerplate(self):
+        markdown = html_to_markdown(PAGE)
+        for boilerplate in ["Home", "Site banner", "Copyright", "trackPageView", "color: red"]:
+            self.assertNotIn(boilerplate, markdown)
+
+    def test_uses_whole_body_without_main(self):
+        markdown = html_to_markdown("<body><nav>Menu</nav><p>Only   paragraph</p></body>")
+        self.assertEqual(markdown, "Only paragraph")
+
+class TestFetchURL(unittest.TestCase):
+    def setUp(self):
+        self.cache_dir = tempfile.TemporaryDirectory()
+        self.cache_patch = mock.patch.object(bootstrap, 'FETCH_CACHE_FOLDER', self.cache_dir.name)
+        self.cache_patch.start()
+
+    def tearDown(self):
+        self.cache_patch.stop()
+        self.cache_dir.cleanup()
+
+    def mock_response(self, text, content_type="text/html; charset=utf-8"):
+        response = mock.Mock()
+        body = text.encode('utf-8')
+        response.iter_content = lambda chunk_size: (body[i:i + chunk_size] for i in range(0, len(body), chunk_size))
+        response.headers = {"Content-Type": content_type}
+        return response
+
+    def test_rejects_non_http_urls(self):
+        content, success = fetch_url("file:///etc/passwd")
+        self.assertFalse(success)
+
+    def test_converts_and_caches_page(self):
+        with mock.patch.object(bootstrap.requests, 'get', return_value=self.mock_response(PAGE), create=True) as get:
+            content, success = fetch_url("https://example.com/widgets")
+            self.assertTrue(success)
+            self.assertIn("# Widgets", content)
+            content_again, _ = fetch_url("https://example.com/widgets")
+            self.assertEqual(content, content_again)
+            self.assertEqual(get.call_count, 1)
+
+    def test_truncates_to_token_budget(self):
+        long_text = "\n".join(f"line {i}" for i in range(10000))
+        with mock.patch.object(bootstrap.requests, 'get', return_value=self.mock_response(long_text, "text/plain"), create=True):
+            content, success = fetch_url("https://example.com/long.txt")
+        self.assertTrue(success)
+        self.assertLess(len(content), bootstrap.FETCH_TOKEN_BUDGET * 4 + 100)
+        self.assertIn("<TRUNCATED", content)
+
+    def test_truncates_single_line_content(self):
+        minified = '{"items": [' + ','.join('{"id": %d}' % i for i in range(20000)) + ']}'
+        with mock.patch.object(bootstrap.requests, 'get', return_value=self.mock_response(minified, "application/json"), create=True):
+            content, success = fetch_url("https://example.com/api.json")
+        self.assertTrue(success)
+        self.assertTrue(content.startswith(minified[:bootstrap.FETCH_TOKEN_BUDGET * 4]))
+        self.assertLess(len(content), bootstrap.FETCH_TOKEN_BUDGET * 4 + 100)
+        self.assertIn("<TRUNCATED", content)
+
+    def test_stops_downloading_at_max_bytes(self):
+        response = self.mock_response("x" * 500000, "text/plain")
+        with mock.patch.object(bootstrap, 'FETCH_MAX_BYTES', 100000), \
+             mock.patch.object(bootstrap, 'FETCH_TOKEN_BUDGET', 1000000), \
+             mock.patch.object(bootstrap.requests, 'get', return_value=response, create=True) as get:
+            content, success = fetch_url("https://example.com/huge.txt")
+        self.assertTrue(success)
+        self.assertEqual(content, "x" * 100000)
+        self.assertTrue(get.call_args.kwargs["stream"])
+        response.close.assert_called()
+
+    def test_decodes_declared_charset(self):
+        response = self.mock_response("", "text/plain; charset=iso-8859-1")
+        response.iter_content = lambda chunk_size: iter([b"caf\xe9"])
+        with mock.patch.object(bootstrap.requests, 'get', return_value=response, create=True):
+            content, success = fetch_url("https://example.com/latin1.txt")
+        self.assertEqual(content, "caf\u00e9")
+
+    def test_rejects_binary_content(self):
+        with mock.patch.object(bootstrap.requests, 'get', return_value=self.mock_response("", "application/pdf"), create=True):
+            content, success = fetch_url("https://example.com/file.pdf")
+        self.assertFalse(success)
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,143 @@
+# MIT License
+# 
+# Copyright (c) 2024 Oren Collaco
+# 
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+# 
+# The above copyright notice and this permission notice shall be included in all
+# copies or substantial portions of the Software.
+# 
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+
+import os
+import tempfile
+import unittest
+
+import sys
+sys.path.append('..') 
+from bootstrap import parse_inspect_spec, find_symbol_lines, read_file_section
+
+PYTHON_SOURCE = """import functools
+
+def helper():
+    return 1
+
+class Server:
+    def __init__(self):
+        self.port = 8080
+
+    @functools.lru_cache()
+    @staticmethod
+    def start(port):
+        return port
+
+    async def stop(self):
+        pass
+"""
+
+GO_SOURCE = """package server
+
+type Server struct {
+	port int
+}
+
+func Start() error {
+	return nil
+}
+
+func (s *Server) Start() error {
+	if s.port == 0 {
+		return nil
+	}
+	return nil
+}
+"""
+
+class TestParseInspectSpec(unittest.TestCase):
+    def test_plain_path(self):
+        self.assertEqual(parse_inspect_spec("src/app.py"), ("src/app.py", None, None))
+
+    def test_line_range(self):
+        self.assertEqual(parse_inspect_spec("src/app.py:10-20"), ("src/app.py", (10, 20), None))
+
+    def test_single_line(self):
+        self.assertEqual(parse_inspect_spec("src/app.py:42"), ("src/app.py", (42, 42), None))
+
+    def test_symbol(self):
+        self.assertEqual(parse_inspect_spec("server.go#Server.Start"), ("server.go", None, "Server.Start"))
+
+    def test_windows_style_colon_is_not_a_range(self):
+        self.assertEqual(parse_inspect_spec("C:/src/app.py"), ("C:/src/app.py", None, None))
+
+class TestFindSymbolLines(unittest.TestCase):
+    def test_python_function(self):
+        self.assertEqual(find_symbol_lines(PYTHON_SOURCE, "app.py", "helper"), (3, 4))
+
+    def test_python_decorated_method_includes_decorators(self):
+        self.assertEqual(find_symbol_lines(PYTHON_SOURCE, "app.py", "Server.start"), (10, 13))
+
+    def test_python_async_method(self):
+        self.assertEqual(find_symbol_lines(PYTHON_SOURCE, "app.py", "Server.stop"), (15, 16))
+
+    def test_python_class(self):
+        self.assertEqual(find_symbol_lines(PYTHON_SOURCE, "app.py", "Server"), (6, 16))
+
+    def test_python_missing_symbol(self):
+        self.assertIsNone(find_symbol_lines(PYTHON_SOURCE, "app.py", "Server.restart"))
+        self.assertIsNone(find_symbol_lines(PYTHON_SOURCE, "app.py", "start"))
+
+    def test_go_receiver_method(self):
+        self.assertEqual(find_symbol_lines(GO_SOURCE, "server.go", "Server.Start"), (11, 16))
+
+    def test_go_function_preferred_over_method(self):
+        self.assertEqual(find_symbol_lines(GO_SOURCE, "server.go", "Start"), (7, 9))
+
+    def test_go_type(self):
+        self.assertEqual(find_symbol_lines(GO_SOURCE, "server.go", "Server"), (3, 5))
+
+    def test_go_missing_symbol(self):
+        self.assertIsNone(find_symbol_lines(GO_SOURCE, "server.go", "Server.Stop"))
+
+class TestReadFileSection(unittest.TestCase):
+    def setUp(self):
+        self.temp_dir = tempfile.TemporaryDirectory()
+        self.file_path = os.path.join(self.temp_dir.name, "server.go")
+        with open(self.file_path, 'w') as f:
+            f.write(GO_SOURCE)
+
+    def tearDown(self):
+        self.temp_dir.cleanup()
+
+    def test_line_range_keeps_original_line_numbers(self):
+        result = read_file_section(self.file_path, line_range=(7, 8))
+        self.assertEqual(result, "Lines 7-8 of 17\n7:func Start() error {\n8:\treturn nil")
+
+    def test_symbol(self):
+        result = read_file_section(self.file_path, symbol="Server.Start")
+        self.assertTrue(result.startswith("Lines 11-16 of 17 (Server.Start)\n11:func (s *Server) Start() error {"))
+        self.assertTrue(result.endswith("16:}"))
+
+    def test_missing_symbol(self):
+        self.assertEqual(read_file_section(self.file_path, symbol="Stop"), f"Error: Symbol 'Stop' not found in {self.file_path}")
+
+    def test_range_past_end_is_clamped(self):
+        self.assertTrue(read_file_section(self.file_path, line_range=(16, 100)).startswith("Lines 16-17 of 17\n16:}"))
+
+    def test_out_of_range_span(self):
+        for line_range in [(0, 3), (18, 25), (8, 7)]:
+            self.assertTrue(read_file_section(self.file_path, line_range=line_range).startswith("Error: Invalid line range"), line_range)
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,97 @@
+# MIT License
+# 
+# Copyright (c) 2024 Oren Collaco
+# 
+# Permission is hereby granted, free of charge, to any person obtaining a copy
+# of this software and associated documentation files (the "Software"), to deal
+# in the Software without restriction, including without limitation the rights
+# to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+# copies of the Software, and to permit persons to whom the Software is
+# furnished to do so, subject to the following conditions:
+# 
+# The above copyright notice and this permission notice shall be included in all
+# copies or substantial portions of the Software.
+# 
+# THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+# IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+# FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+# AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+# LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+# OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+# SOFTWARE.
+
+
+import os
+import re
+import tempfile
+import unittest
+from unittest import mock
+
+import sys
+sys.path.append('..') 
+import bootstrap
+from bootstrap import truncate_output
+
+class TestTruncateOutput(unittest.TestCase):
+    def setUp(self):
+        self.log_dir = tempfile.TemporaryDirectory()
+        self.patch = mock.patch.object(bootstrap, 'OUTPUT_LOG_FOLDER', self.log_dir.name)
+        self.patch.start()
+        # 1000 lines of 10 characters each
+        self.output = ''.join(f"line {i:04d}\n" for i in range(1000))
+
+    def tearDown(self):
+        self.patch.stop()
+        self.log_dir.cleanup()
+
+    def test_short_output_is_unchanged(self):
+        self.assertEqual(truncate_output("ok\n", "RUN: go test"), "ok\n")
+        self.assertEqual(os.listdir(self.log_dir.name), [])
+
+    def test_keeps_whole_head_and_tail_lines(self):
+        result = truncate_output(self.output, "RUN: go test ./...", max_length=1000)
+        self.assertTrue(result.startswith("line 0000\n"))
+        self.assertTrue(result.endswith("line 0999\n"))
+        self.assertIn("line 0049\n<TRUNCATED", result)
+        self.assertIn(">\nline 0951\n", result)
+        self.assertNotIn("line 0050", result)
+        self.assertNotIn("line 0950", result)
+
+    def test_truncation_message_counts_omitted_content(self):
+        result = truncate_output(self.output, "RUN: go test ./...", max_length=1000)
+        self.assertIn("<TRUNCATED 901 lines and 8109 characters, full output saved to ", result)
+
+    def test_full_output_is_saved_to_log(self):
+        result = truncate_output(self.output, "RUN: go test ./...", max_length=1000)
+        log_path = re.search(r"full output saved to (.+)>", result).group(1)
+        self.assertEqual(os.path.dirname(log_path), self.log_dir.name)
+        self.assertTrue(os.path.basename(log_path).endswith("_RUN_go_test.log"))
+        with open(log_path) as f:
+            self.assertEqual(f.read(), self.output)
+
+    def test_default_limit_follows_max_output_length(self):
+        with mock.patch.object(bootstrap, 'MAX_OUTPUT_LENGTH', 1000):
+            result = truncate_output(self.output, "RUN: go test ./...")
+        self.assertIn("<TRUNCATED 901 lines", result)
+        self.assertEqual(truncate_output(self.output, "RUN: go test ./..."), self.output)
+
+    def test_output_without_newlines(self):
+        result = truncate_output("x" * 5000, "RUN: cat blob", max_length=1000)
+        self.assertTrue(result.startswith("x" * 500 + "\n<TRUNCATED 0 lines and 4000 characters"))
+        self.assertTrue(result.endswith(">\n" + "x" * 500))
+
+    def test_unwritable_log_folder(self):
+        with mock.patch.object(bootstrap, 'OUTPUT_LOG_FOLDER', os.path.join(self.log_dir.name, "file")):
+            open(os.path.join(self.log_dir.name, "file"), 'w').close()
+            result = truncate_output(self.output, "RUN: go test", max_length=1000)
+        self.assertIn("full output could not be saved", result)
+
+class TestCheckOutput(unittest.TestCase):
+    def test_check_without_running_process(self):
+        with mock.patch.object(bootstrap, 'running_processes', []):
+            output, success = bootstrap.execute_command("CHECK: python3 server.py")
+        self.assertFalse(success)
+        self.assertIn("No running process found for 'python3 server.py'", output)
+
+if __name__ == '__main__':
+    unittest.main()